@@ -0,0 +1,90 @@
+// Package event provides a minimal typed publish/subscribe dispatcher used
+// to decouple subsystems (mining pool, stratum, API server, ...) from one
+// another: a publisher Posts a concrete event value, and a subscriber
+// Subscribes by passing a zero value of the event type it wants delivered.
+package event
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+)
+
+// Subscription is a live registration returned by Dispatcher.Subscribe.
+// Read from Chan() until Unsubscribe is called.
+type Subscription struct {
+	dispatcher *Dispatcher
+	typ        reflect.Type
+	ch         chan interface{}
+	once       sync.Once
+}
+
+// Chan returns the channel events of the subscribed type are delivered on.
+// It is closed once Unsubscribe is called.
+func (s *Subscription) Chan() chan interface{} {
+	return s.ch
+}
+
+// Unsubscribe removes the subscription and closes its channel. Safe to call
+// more than once.
+func (s *Subscription) Unsubscribe() {
+	s.once.Do(func() {
+		s.dispatcher.unsubscribe(s)
+		close(s.ch)
+	})
+}
+
+// Dispatcher fans a Post'd event out to every Subscription registered for
+// its concrete type.
+type Dispatcher struct {
+	mutex sync.RWMutex
+	subs  map[reflect.Type]map[*Subscription]struct{}
+}
+
+// NewDispatcher creates an empty Dispatcher.
+func NewDispatcher() *Dispatcher {
+	return &Dispatcher{subs: make(map[reflect.Type]map[*Subscription]struct{})}
+}
+
+// Subscribe registers for events sharing kind's concrete type, e.g.
+// dispatcher.Subscribe(NewMinedBlockEvent{}).
+func (d *Dispatcher) Subscribe(kind interface{}) (*Subscription, error) {
+	typ := reflect.TypeOf(kind)
+	if typ == nil {
+		return nil, errors.New("event: cannot subscribe to untyped nil")
+	}
+
+	sub := &Subscription{dispatcher: d, typ: typ, ch: make(chan interface{}, 1)}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.subs[typ] == nil {
+		d.subs[typ] = make(map[*Subscription]struct{})
+	}
+	d.subs[typ][sub] = struct{}{}
+	return sub, nil
+}
+
+func (d *Dispatcher) unsubscribe(sub *Subscription) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	delete(d.subs[sub.typ], sub)
+}
+
+// Post delivers obj to every subscription registered for its concrete type.
+// Delivery is non-blocking: a subscriber whose channel is already full
+// misses the event rather than stalling the publisher.
+func (d *Dispatcher) Post(obj interface{}) error {
+	typ := reflect.TypeOf(obj)
+
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
+	for sub := range d.subs[typ] {
+		select {
+		case sub.ch <- obj:
+		default:
+		}
+	}
+	return nil
+}
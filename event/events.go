@@ -0,0 +1,67 @@
+package event
+
+import (
+	"github.com/bytom/protocol/bc"
+	"github.com/bytom/protocol/bc/types"
+)
+
+// NewMinedBlockEvent is posted whenever a pool-submitted block is
+// successfully written to the chain.
+type NewMinedBlockEvent struct {
+	Block *types.Block
+}
+
+// NewTxEvent is posted whenever a new transaction is accepted into the
+// pool, independent of whether it ever ends up in a mined block.
+// protocol.TxPool has no way to publish through a Dispatcher directly, so
+// this is bridged from its own notification channel (see
+// MiningPool.txNotifier) rather than posted from inside TxPool itself.
+type NewTxEvent struct {
+	Tx *types.Tx
+}
+
+// MinedBlockConfirmedEvent is posted when a block this pool submitted is
+// still on the canonical chain once the tip has advanced far enough past
+// it to be considered settled.
+type MinedBlockConfirmedEvent struct {
+	Height uint64
+	Hash   bc.Hash
+}
+
+// MinedBlockReorgedEvent is posted when a block this pool submitted is no
+// longer on the canonical chain at its height once the tip has advanced far
+// enough past it to check.
+type MinedBlockReorgedEvent struct {
+	Height uint64
+	Hash   bc.Hash
+}
+
+// NewBlockTemplateEvent is posted whenever MiningPool builds a fresh block
+// template, carrying everything a stratum-style front end needs to push a
+// mining.notify without calling back into the pool.
+type NewBlockTemplateEvent struct {
+	Header       types.BlockHeader
+	Commitment   types.BlockCommitment
+	Transactions []*types.Tx
+	Height       uint64
+}
+
+// SubmitAcceptedEvent is posted when a submitted block header passes
+// validation and is written to the chain.
+type SubmitAcceptedEvent struct {
+	Header types.BlockHeader
+}
+
+// SubmitRejectedEvent is posted when a submitted block header is rejected,
+// either during preflight validation or by chain processing.
+type SubmitRejectedEvent struct {
+	Header types.BlockHeader
+	Reason string
+}
+
+// TemplateStaleEvent is posted whenever MiningPool drops its commitMap and
+// cached template, so subscribers know any job handles built against the
+// old template are no longer redeemable.
+type TemplateStaleEvent struct {
+	Reason string
+}
@@ -0,0 +1,66 @@
+package miningpool
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Metrics is a point-in-time snapshot of the submission pipeline, exposed so
+// operators can poll submit-validation latency separately from
+// chain-processing latency and tell a slow ProcessBlock from a flood of bad
+// shares.
+type Metrics struct {
+	ValidationCount   uint64
+	ValidationLatency time.Duration
+	RejectedCount     uint64
+	ProcessCount      uint64
+	ProcessLatency    time.Duration
+}
+
+// pipelineMetrics accumulates running totals. Latency is tracked as a
+// running average rather than a full histogram, since the pool only needs
+// rough visibility into validation-vs-processing cost, not percentiles.
+type pipelineMetrics struct {
+	validationCount uint64
+	validationNanos uint64
+	rejectedCount   uint64
+	processCount    uint64
+	processNanos    uint64
+}
+
+func (p *pipelineMetrics) observeValidation(d time.Duration, accepted bool) {
+	atomic.AddUint64(&p.validationCount, 1)
+	atomic.AddUint64(&p.validationNanos, uint64(d.Nanoseconds()))
+	if !accepted {
+		atomic.AddUint64(&p.rejectedCount, 1)
+	}
+}
+
+func (p *pipelineMetrics) observeProcess(d time.Duration) {
+	atomic.AddUint64(&p.processCount, 1)
+	atomic.AddUint64(&p.processNanos, uint64(d.Nanoseconds()))
+}
+
+func avgDuration(totalNanos, count uint64) time.Duration {
+	if count == 0 {
+		return 0
+	}
+	return time.Duration(totalNanos / count)
+}
+
+func (p *pipelineMetrics) snapshot() Metrics {
+	validationCount := atomic.LoadUint64(&p.validationCount)
+	processCount := atomic.LoadUint64(&p.processCount)
+	return Metrics{
+		ValidationCount:   validationCount,
+		ValidationLatency: avgDuration(atomic.LoadUint64(&p.validationNanos), validationCount),
+		RejectedCount:     atomic.LoadUint64(&p.rejectedCount),
+		ProcessCount:      processCount,
+		ProcessLatency:    avgDuration(atomic.LoadUint64(&p.processNanos), processCount),
+	}
+}
+
+// Metrics returns a snapshot of the pool's submission pipeline health.
+func (m *MiningPool) Metrics() Metrics {
+	return m.metrics.snapshot()
+}
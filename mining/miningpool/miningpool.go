@@ -15,140 +15,470 @@ import (
 )
 
 const (
-	maxSubmitChSize = 50
+	// maxResultChSize bounds how many validated submissions can be queued
+	// for chain processing before SubmitWork blocks on enqueueing; either
+	// way SubmitWork then waits for its own submission's ProcessBlock result.
+	maxResultChSize = 50
+
+	// minRecommitInterval and maxRecommitInterval bound the adaptive
+	// recommit scheduler, mirroring the [1s, 15s] range used by eth's miner.
+	minRecommitInterval = 1 * time.Second
+	maxRecommitInterval = 15 * time.Second
+
+	// defaultRecommitInterval is the starting point for the adaptive
+	// scheduler before any ticks or user feedback have adjusted it.
+	defaultRecommitInterval = 3 * time.Second
+
+	// recommitAdjustFactor is the decay weight (k) applied when the
+	// scheduler nudges recommit towards its target each tick.
+	recommitAdjustFactor = 0.1
+
+	// recommitSnapTolerance is how close recommit must get to its decay
+	// target before adjustRecommit snaps it the rest of the way. The decay
+	// is geometric, so it only approaches minRecommit/maxRecommitInterval
+	// asymptotically; without a snap it would take hundreds of ticks to
+	// land on the bound exactly, and would never quite get there at all.
+	recommitSnapTolerance = 1 * time.Millisecond
 )
 
-// TODO:
-// 1. adjust recomit interval
-// 2. custom recomit interval
-var recommitTicker = time.NewTicker(3 * time.Second) // RecommitInterval for eth lies in [1s, 15s]
+// recommitAdjustMsg carries a user-requested bias towards fresher (negative
+// ratio) or staler (positive ratio) work, e.g. from a GPU miner.
+type recommitAdjustMsg struct {
+	ratio float64
+}
+
+// sealResult is a validated submission waiting for its turn on the chain,
+// already assembled into a full block by preflight.
+type sealResult struct {
+	block *types.Block
+	reply chan error
+}
 
-type submitBlockMsg struct {
-	blockHeader *types.BlockHeader
-	reply       chan error
+// pending is the cached block template and the time it was built. The
+// commitMap entry needed to validate a later submission is kept separately,
+// in MiningPool.commitMap.
+type pending struct {
+	block     *types.Block
+	createdAt time.Time
 }
 
 // MiningPool is the support struct for p2p mine pool
 type MiningPool struct {
 	mutex     sync.RWMutex
-	block     *types.Block
-	submitCh  chan *submitBlockMsg
+	pending   *pending
+	resultCh  chan *sealResult
 	commitMap map[types.BlockCommitment]([]*types.Tx)
 
+	minRecommit  time.Duration
+	recommit     time.Duration
+	lastTxCount  int
+	userAdjustCh chan *recommitAdjustMsg
+	resetCh      chan struct{}
+
+	unconfirmed *UnconfirmedBlocks
+	metrics     pipelineMetrics
+
 	chain           *protocol.Chain
 	accountManager  *account.Manager
 	txPool          *protocol.TxPool
 	eventDispatcher *event.Dispatcher
 }
 
-// NewMiningPool will create a new MiningPool
+// NewMiningPool will create a new MiningPool. The first template is built
+// lazily on the first GetWork call rather than eagerly here.
 func NewMiningPool(c *protocol.Chain, accountManager *account.Manager, txPool *protocol.TxPool, dispatcher *event.Dispatcher) *MiningPool {
 	m := &MiningPool{
-		submitCh:        make(chan *submitBlockMsg, maxSubmitChSize),
+		resultCh:        make(chan *sealResult, maxResultChSize),
 		commitMap:       make(map[types.BlockCommitment]([]*types.Tx)),
+		minRecommit:     defaultRecommitInterval,
+		recommit:        defaultRecommitInterval,
+		userAdjustCh:    make(chan *recommitAdjustMsg),
+		resetCh:         make(chan struct{}, 1),
+		unconfirmed:     NewUnconfirmedBlocks(unconfirmedBlockDepth),
 		chain:           c,
 		accountManager:  accountManager,
 		txPool:          txPool,
 		eventDispatcher: dispatcher,
 	}
-	m.generateBlock()
 	go m.blockUpdater()
+	go m.resultProcessor()
+	go m.txNotifier()
 	return m
 }
 
-// blockUpdater is the goroutine for keep update mining block
+// txNotifier bridges protocol.TxPool's own new-transaction notifications
+// onto eventDispatcher as event.NewTxEvent, since TxPool has no way to
+// publish through an event.Dispatcher directly. It returns only once the
+// TxPool's notification channel is closed.
+func (m *MiningPool) txNotifier() {
+	for msg := range m.txPool.GetMsgCh() {
+		if msg.MsgTx == nil {
+			continue
+		}
+		if err := m.eventDispatcher.Post(event.NewTxEvent{Tx: msg.MsgTx}); err != nil {
+			log.Errorf("miningpool: failed to post NewTxEvent: %v", err)
+		}
+	}
+}
+
+// SetRecommitInterval sets the user floor for the adaptive recommit
+// scheduler. The pool will never recommit faster than the given interval,
+// bounded to [minRecommitInterval, maxRecommitInterval]. blockUpdater's
+// timer is reset immediately so a shrunk interval takes effect right away,
+// rather than only after the previous, longer-running tick fires.
+func (m *MiningPool) SetRecommitInterval(interval time.Duration) {
+	if interval < minRecommitInterval {
+		interval = minRecommitInterval
+	}
+	if interval > maxRecommitInterval {
+		interval = maxRecommitInterval
+	}
+
+	m.mutex.Lock()
+	m.minRecommit = interval
+	if m.recommit < interval {
+		m.recommit = interval
+	}
+	m.mutex.Unlock()
+
+	select {
+	case m.resetCh <- struct{}{}:
+	default:
+	}
+}
+
+// recommitInterval returns the current recommit interval under a read lock;
+// it is mutated concurrently by adjustRecommit, applyUserAdjust, and
+// SetRecommitInterval from other goroutines.
+func (m *MiningPool) recommitInterval() time.Duration {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.recommit
+}
+
+// Subscribe returns a typed event.Subscription for kind, e.g.
+// pool.Subscribe(event.NewBlockTemplateEvent{}). This lets external
+// subsystems (API server, stratum, metrics) react to pool activity without
+// polling GetWork.
+func (m *MiningPool) Subscribe(kind interface{}) (*event.Subscription, error) {
+	return m.eventDispatcher.Subscribe(kind)
+}
+
+// AdjustRecommitInterval biases the adaptive recommit target by ratio, a
+// value in [-1, 1] (i.e. [-100%, 100%]). A negative ratio asks for fresher
+// work (shorter recommit), a positive ratio tolerates staler work. This lets
+// GPU miners push the pool towards producing fresher templates.
+func (m *MiningPool) AdjustRecommitInterval(ratio float64) error {
+	if ratio < -1 || ratio > 1 {
+		return errors.New("recommit adjust ratio must be in [-1, 1]")
+	}
+	m.userAdjustCh <- &recommitAdjustMsg{ratio: ratio}
+	return nil
+}
+
+// blockUpdater is the goroutine that keeps the pool informed of new chain
+// and tx-pool state. It never builds a template itself: it only marks the
+// cached template stale so the next GetWork call rebuilds it on demand,
+// except for the recommit tick, which also drives the adaptive scheduler.
 func (m *MiningPool) blockUpdater() {
+	recommitTimer := time.NewTimer(m.recommitInterval())
+	defer recommitTimer.Stop()
+
+	txSub, err := m.Subscribe(event.NewTxEvent{})
+	if err != nil {
+		log.Errorf("miningpool: failed on subscribe NewTxEvent: %v", err)
+	} else {
+		defer txSub.Unsubscribe()
+	}
+
 	for {
 		select {
-		case <-recommitTicker.C:
-			m.generateBlock()
+		case <-recommitTimer.C:
+			m.tickAdjust()
+			recommitTimer.Reset(m.recommitInterval())
+
+		case adjust := <-m.userAdjustCh:
+			m.applyUserAdjust(adjust.ratio)
+			recommitTimer.Reset(m.recommitInterval())
+
+		case <-subChan(txSub):
+			m.markStale()
+
+		case <-m.resetCh:
+			recommitTimer.Reset(m.recommitInterval())
 
 		case <-m.chain.BlockWaiter(m.chain.BestBlockHeight() + 1):
-			// make a new commitMap, so that the expired map will be deleted(garbage-collected)
-			m.commitMap = make(map[types.BlockCommitment]([]*types.Tx))
-			m.generateBlock()
-
-		case submitMsg := <-m.submitCh:
-			err := m.submitWork(submitMsg.blockHeader)
-			if err == nil {
-				// make a new commitMap, so that the expired map will be deleted(garbage-collected)
-				m.commitMap = make(map[types.BlockCommitment]([]*types.Tx))
-				m.generateBlock()
-			}
-			submitMsg.reply <- err
+			m.invalidateTemplate("best block changed")
+			m.unconfirmed.Shift(m.chain.BestBlockHeight(), m.chain, m.eventDispatcher)
+			recommitTimer.Reset(m.recommitInterval())
 		}
 	}
 }
 
-// generateBlock generates a block template to mine
-func (m *MiningPool) generateBlock() {
+// resultProcessor is the single goroutine that owns chain writes: it drains
+// validated submissions from resultCh and runs them through ProcessBlock one
+// at a time, so bursts of concurrently-preflighted shares never contend with
+// each other for the chain, while still leaving blockUpdater free to keep
+// ticking the recommit timer and watching for new blocks.
+func (m *MiningPool) resultProcessor() {
+	for result := range m.resultCh {
+		start := time.Now()
+		err := m.processResult(result.block)
+		m.metrics.observeProcess(time.Since(start))
+		result.reply <- err
+	}
+}
+
+// processResult writes a preflighted block to the chain and, on success,
+// announces it and invalidates the cached template so the next GetWork
+// builds on top of it.
+func (m *MiningPool) processResult(block *types.Block) error {
+	isOrphan, err := m.chain.ProcessBlock(block)
+	if err == nil && isOrphan {
+		err = errors.New("submit result is orphan")
+	}
+	if err != nil {
+		m.postSubmitEvent(block.BlockHeader, err)
+		return err
+	}
+
+	if err := m.eventDispatcher.Post(event.NewMinedBlockEvent{Block: block}); err != nil {
+		return err
+	}
+	m.postSubmitEvent(block.BlockHeader, nil)
+	m.unconfirmed.Insert(block.Height, block.Hash())
+	m.invalidateTemplate("submission accepted")
+
+	return nil
+}
+
+// postSubmitEvent announces the outcome of a submission as either
+// SubmitAcceptedEvent or SubmitRejectedEvent, so subscribers (API server,
+// stratum, metrics) don't need to poll GetWork/SubmitWork to see it.
+func (m *MiningPool) postSubmitEvent(bh types.BlockHeader, submitErr error) {
+	var err error
+	if submitErr != nil {
+		err = m.eventDispatcher.Post(event.SubmitRejectedEvent{Header: bh, Reason: submitErr.Error()})
+	} else {
+		err = m.eventDispatcher.Post(event.SubmitAcceptedEvent{Header: bh})
+	}
+	if err != nil {
+		log.Errorf("miningpool: failed to post submit event: %v", err)
+	}
+}
+
+// invalidateTemplate resets commitMap (so the expired map is garbage
+// collected) and marks the cached template stale, announcing why via
+// TemplateStaleEvent.
+func (m *MiningPool) invalidateTemplate(reason string) {
+	m.mutex.Lock()
+	m.commitMap = make(map[types.BlockCommitment]([]*types.Tx))
+	m.mutex.Unlock()
+	m.markStale()
+
+	if err := m.eventDispatcher.Post(event.TemplateStaleEvent{Reason: reason}); err != nil {
+		log.Errorf("miningpool: failed to post TemplateStaleEvent: %v", err)
+	}
+}
+
+// subChan returns sub's channel, or nil if sub is nil (subscribe failed).
+// Reading from a nil channel blocks forever, so this just disables the case.
+func subChan(sub *event.Subscription) chan interface{} {
+	if sub == nil {
+		return nil
+	}
+	return sub.Chan()
+}
+
+// markStale drops the cached template so the next GetWork rebuilds it.
+func (m *MiningPool) markStale() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.pending = nil
+}
+
+// adjustRecommit nudges recommit towards minRecommit when the last template
+// carried meaningful new transactions, or towards maxRecommitInterval when it
+// was empty/identical, using an exponential decay so the interval settles
+// smoothly instead of oscillating. The decay only approaches its target
+// asymptotically, so once recommit is within recommitSnapTolerance of it,
+// snap the rest of the way instead of crawling towards it for hundreds more
+// ticks.
+func (m *MiningPool) adjustRecommit(hadNewTxs bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	target := maxRecommitInterval
+	if hadNewTxs {
+		target = m.minRecommit
+	}
+	m.recommit = time.Duration(float64(m.recommit)*(1-recommitAdjustFactor) + float64(target)*recommitAdjustFactor)
+	if d := m.recommit - target; d > -recommitSnapTolerance && d < recommitSnapTolerance {
+		m.recommit = target
+	}
+	if m.recommit < m.minRecommit {
+		m.recommit = m.minRecommit
+	}
+	if m.recommit > maxRecommitInterval {
+		m.recommit = maxRecommitInterval
+	}
+}
+
+// tickAdjust feeds the adaptive recommit scheduler once per recommit tick,
+// comparing the tx count of whatever template is currently cached (built by
+// the last GetWork call, possibly several ticks ago) against the count
+// retained from the previous tick. Reading pending before clearing it, and
+// comparing against a snapshot that survives the clear, keeps the signal
+// meaningful on an idle node: comparing against m.pending itself would
+// always see nil here and bias towards minRecommit regardless of real tx
+// activity. It then marks the template stale so the next GetWork rebuilds
+// it on top of whatever the chain/tx pool look like now.
+func (m *MiningPool) tickAdjust() {
+	m.mutex.Lock()
+	txCount := 0
+	if m.pending != nil {
+		txCount = len(m.pending.block.Transactions)
+	}
+	hadNewTxs := txCount > m.lastTxCount
+	m.lastTxCount = txCount
+	m.mutex.Unlock()
+
+	m.adjustRecommit(hadNewTxs)
+	m.markStale()
+}
+
+// applyUserAdjust biases recommit by ratio, then clamps it back to
+// [minRecommit, maxRecommitInterval].
+func (m *MiningPool) applyUserAdjust(ratio float64) {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 
+	m.recommit = time.Duration(float64(m.recommit) * (1 + ratio))
+	if m.recommit < m.minRecommit {
+		m.recommit = m.minRecommit
+	}
+	if m.recommit > maxRecommitInterval {
+		m.recommit = maxRecommitInterval
+	}
+}
+
+// generateBlock builds a new block template and caches it as pending.
+// Feeding the adaptive recommit scheduler happens separately, once per
+// tick, in tickAdjust; see its doc comment for why.
+func (m *MiningPool) generateBlock() error {
 	block, err := mining.NewBlockTemplate(m.chain, m.txPool, m.accountManager)
 	if err != nil {
 		log.Errorf("miningpool: failed on create NewBlockTemplate: %v", err)
-		return
+		return err
 	}
 
-	// block will not be nil here
-	m.block = block
+	m.mutex.Lock()
+	m.pending = &pending{block: block, createdAt: time.Now()}
 	m.commitMap[block.BlockCommitment] = block.Transactions
+	m.mutex.Unlock()
+
+	if err := m.eventDispatcher.Post(event.NewBlockTemplateEvent{
+		Header:       block.BlockHeader,
+		Commitment:   block.BlockCommitment,
+		Transactions: block.Transactions,
+		Height:       block.Height,
+	}); err != nil {
+		log.Errorf("miningpool: failed to post NewBlockTemplateEvent: %v", err)
+	}
+
+	return nil
 }
 
-// GetWork will return a block header for p2p mining
+// needsRebuild reports whether the cached template is missing, belongs to a
+// stale parent, or has outlived the current recommit interval.
+func (m *MiningPool) needsRebuild() bool {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	if m.pending == nil {
+		return true
+	}
+	if m.pending.block.Height != m.chain.BestBlockHeight()+1 {
+		return true
+	}
+	if m.pending.block.PreviousBlockHash != *m.chain.BestBlockHash() {
+		return true
+	}
+	return time.Since(m.pending.createdAt) > m.recommit
+}
+
+// GetWork will return a block header for p2p mining, lazily building (or
+// rebuilding) the cached template if it is missing or stale. It stamps a
+// fresh Timestamp on its own copy of the cached header rather than the
+// cached header itself: GetWork only holds an RLock, which concurrent
+// callers can all acquire at once, so mutating the shared header here would
+// race with another GetWork call doing the same thing concurrently.
 func (m *MiningPool) GetWork() (*types.BlockHeader, error) {
-	if m.block != nil {
-		m.mutex.RLock()
-		defer m.mutex.RUnlock()
+	if m.needsRebuild() {
+		if err := m.generateBlock(); err != nil {
+			return nil, err
+		}
+	}
 
-		m.block.BlockHeader.Timestamp = uint64(time.Now().Unix())
-		bh := m.block.BlockHeader
-		return &bh, nil
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	if m.pending == nil {
+		return nil, errors.New("no block is ready for mining")
 	}
-	return nil, errors.New("no block is ready for mining")
+	bh := m.pending.block.BlockHeader
+	bh.Timestamp = uint64(time.Now().Unix())
+	return &bh, nil
 }
 
-// SubmitWork will try to submit the result to the blockchain
+// SubmitWork will try to submit the result to the blockchain. Validation
+// against the current template runs immediately in the caller's goroutine
+// (preflight only takes a read lock), so many miners can submit at once
+// without serializing behind each other; only the actual chain write is
+// funneled through the single resultProcessor goroutine.
 func (m *MiningPool) SubmitWork(bh *types.BlockHeader) error {
+	start := time.Now()
+	block, err := m.preflight(bh)
+	m.metrics.observeValidation(time.Since(start), err == nil)
+	if err != nil {
+		log.WithFields(log.Fields{"err": err, "height": bh.Height}).Warning("submitWork failed")
+		m.postSubmitEvent(*bh, err)
+		return err
+	}
+
 	reply := make(chan error, 1)
-	m.submitCh <- &submitBlockMsg{blockHeader: bh, reply: reply}
-	err := <-reply
+	m.resultCh <- &sealResult{block: block, reply: reply}
+	err = <-reply
 	if err != nil {
 		log.WithFields(log.Fields{"err": err, "height": bh.Height}).Warning("submitWork failed")
 	}
 	return err
 }
 
-func (m *MiningPool) submitWork(bh *types.BlockHeader) error {
-	m.mutex.Lock()
-	defer m.mutex.Unlock()
+// preflight checks bh against the currently pending template and, if it
+// still applies, assembles a standalone block ready for ProcessBlock. It
+// only takes a read lock, so it is safe to run concurrently for multiple
+// submissions — including concurrently with GetWork, which also only reads
+// pending.block under RLock rather than mutating it in place (see GetWork's
+// doc comment); the old submitWork took a full write Lock, so that
+// mutual exclusion used to be incidental rather than by design.
+func (m *MiningPool) preflight(bh *types.BlockHeader) (*types.Block, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
 
-	if m.block == nil || bh.PreviousBlockHash != m.block.PreviousBlockHash {
-		return errors.New("pending mining block has been changed")
+	if m.pending == nil || bh.PreviousBlockHash != m.pending.block.PreviousBlockHash {
+		return nil, errors.New("pending mining block has been changed")
 	}
 
 	txs, ok := m.commitMap[bh.BlockCommitment]
 	if !ok {
-		return errors.New("BlockCommitment not found in history")
+		return nil, errors.New("BlockCommitment not found in history")
 	}
 
-	m.block.Transactions = txs
-	m.block.BlockCommitment = bh.BlockCommitment
-	m.block.Nonce = bh.Nonce
-	m.block.Timestamp = bh.Timestamp
-
-	isOrphan, err := m.chain.ProcessBlock(m.block)
-	if err != nil {
-		return err
-	}
-	if isOrphan {
-		return errors.New("submit result is orphan")
-	}
-
-	if err := m.eventDispatcher.Post(event.NewMinedBlockEvent{Block: m.block}); err != nil {
-		return err
-	}
-
-	return nil
+	block := *m.pending.block
+	block.Transactions = txs
+	block.BlockCommitment = bh.BlockCommitment
+	block.Nonce = bh.Nonce
+	block.Timestamp = bh.Timestamp
+	return &block, nil
 }
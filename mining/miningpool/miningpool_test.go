@@ -0,0 +1,116 @@
+package miningpool
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bytom/protocol/bc/types"
+)
+
+func TestAdjustRecommitTowardsMin(t *testing.T) {
+	m := &MiningPool{minRecommit: 1 * time.Second, recommit: 10 * time.Second}
+
+	for i := 0; i < 100; i++ {
+		m.adjustRecommit(true)
+	}
+
+	if m.recommit != m.minRecommit {
+		t.Fatalf("recommit did not settle at minRecommit: got %v, want %v", m.recommit, m.minRecommit)
+	}
+}
+
+func TestAdjustRecommitTowardsMax(t *testing.T) {
+	m := &MiningPool{minRecommit: 1 * time.Second, recommit: 1 * time.Second}
+
+	for i := 0; i < 100; i++ {
+		m.adjustRecommit(false)
+	}
+
+	if m.recommit != maxRecommitInterval {
+		t.Fatalf("recommit did not settle at maxRecommitInterval: got %v, want %v", m.recommit, maxRecommitInterval)
+	}
+}
+
+func TestAdjustRecommitStaysWithinBounds(t *testing.T) {
+	m := &MiningPool{minRecommit: 2 * time.Second, recommit: 2 * time.Second}
+
+	for i := 0; i < 50; i++ {
+		m.adjustRecommit(i%2 == 0)
+		if m.recommit < m.minRecommit || m.recommit > maxRecommitInterval {
+			t.Fatalf("recommit escaped bounds after %d ticks: got %v", i, m.recommit)
+		}
+	}
+}
+
+func TestApplyUserAdjustClampsToMin(t *testing.T) {
+	m := &MiningPool{minRecommit: 2 * time.Second, recommit: 3 * time.Second}
+
+	m.applyUserAdjust(-1)
+
+	if m.recommit != m.minRecommit {
+		t.Fatalf("applyUserAdjust(-1) = %v, want clamped to minRecommit %v", m.recommit, m.minRecommit)
+	}
+}
+
+func TestApplyUserAdjustClampsToMax(t *testing.T) {
+	m := &MiningPool{minRecommit: 1 * time.Second, recommit: maxRecommitInterval}
+
+	m.applyUserAdjust(1)
+
+	if m.recommit != maxRecommitInterval {
+		t.Fatalf("applyUserAdjust(1) = %v, want clamped to maxRecommitInterval %v", m.recommit, maxRecommitInterval)
+	}
+}
+
+func TestTickAdjustComparesAgainstRetainedSnapshot(t *testing.T) {
+	m := &MiningPool{
+		minRecommit: 1 * time.Second,
+		recommit:    5 * time.Second,
+		lastTxCount: 2,
+		pending:     &pending{block: &types.Block{Transactions: make([]*types.Tx, 2)}},
+	}
+
+	// No growth since the last tick: recommit should grow towards max, not
+	// shrink towards min, even though markStale() is about to nil out
+	// pending right after.
+	m.tickAdjust()
+
+	if m.recommit <= 5*time.Second {
+		t.Fatalf("recommit = %v, want it to grow when tx count did not increase since the last tick", m.recommit)
+	}
+	if m.pending != nil {
+		t.Fatal("tickAdjust did not mark the template stale")
+	}
+	if m.lastTxCount != 2 {
+		t.Fatalf("lastTxCount = %d, want retained at 2", m.lastTxCount)
+	}
+}
+
+func TestTickAdjustDetectsGrowthSincePreviousTick(t *testing.T) {
+	m := &MiningPool{
+		minRecommit: 1 * time.Second,
+		recommit:    5 * time.Second,
+		lastTxCount: 0,
+		pending:     &pending{block: &types.Block{Transactions: make([]*types.Tx, 3)}},
+	}
+
+	m.tickAdjust()
+
+	if m.recommit >= 5*time.Second {
+		t.Fatalf("recommit = %v, want it to shrink when tx count grew since the last tick", m.recommit)
+	}
+	if m.lastTxCount != 3 {
+		t.Fatalf("lastTxCount = %d, want updated to 3", m.lastTxCount)
+	}
+}
+
+func TestApplyUserAdjustBiasesWithinBounds(t *testing.T) {
+	m := &MiningPool{minRecommit: 1 * time.Second, recommit: 5 * time.Second}
+
+	m.applyUserAdjust(0.2)
+
+	want := time.Duration(float64(5*time.Second) * 1.2)
+	if m.recommit != want {
+		t.Fatalf("applyUserAdjust(0.2) = %v, want %v", m.recommit, want)
+	}
+}
@@ -0,0 +1,92 @@
+package miningpool
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bytom/protocol/bc"
+	"github.com/bytom/protocol/bc/types"
+)
+
+func TestPreflightRejectsWithoutPendingTemplate(t *testing.T) {
+	m := &MiningPool{commitMap: make(map[types.BlockCommitment][]*types.Tx)}
+
+	if _, err := m.preflight(&types.BlockHeader{}); err == nil {
+		t.Fatal("preflight should fail when there is no pending template")
+	}
+}
+
+func TestPreflightRejectsStaleParent(t *testing.T) {
+	m := &MiningPool{
+		commitMap: make(map[types.BlockCommitment][]*types.Tx),
+		pending: &pending{block: &types.Block{BlockHeader: types.BlockHeader{
+			PreviousBlockHash: bc.Hash{V0: 1},
+		}}},
+	}
+
+	bh := &types.BlockHeader{PreviousBlockHash: bc.Hash{V0: 2}}
+	if _, err := m.preflight(bh); err == nil {
+		t.Fatal("preflight should fail when PreviousBlockHash no longer matches the cached template")
+	}
+}
+
+func TestPreflightRejectsUnknownCommitment(t *testing.T) {
+	parent := bc.Hash{V0: 1}
+	m := &MiningPool{
+		commitMap: make(map[types.BlockCommitment][]*types.Tx),
+		pending: &pending{block: &types.Block{BlockHeader: types.BlockHeader{
+			PreviousBlockHash: parent,
+		}}},
+	}
+
+	bh := &types.BlockHeader{PreviousBlockHash: parent, BlockCommitment: types.BlockCommitment{TransactionsMerkleRoot: bc.Hash{V0: 9}}}
+	if _, err := m.preflight(bh); err == nil {
+		t.Fatal("preflight should fail for a commitment not in commitMap")
+	}
+}
+
+func TestPreflightAssemblesBlockForKnownCommitment(t *testing.T) {
+	parent := bc.Hash{V0: 1}
+	commitment := types.BlockCommitment{TransactionsMerkleRoot: bc.Hash{V0: 9}}
+	txs := []*types.Tx{{}}
+
+	m := &MiningPool{
+		commitMap: map[types.BlockCommitment][]*types.Tx{commitment: txs},
+		pending: &pending{block: &types.Block{BlockHeader: types.BlockHeader{
+			PreviousBlockHash: parent,
+		}}},
+	}
+
+	bh := &types.BlockHeader{PreviousBlockHash: parent, BlockCommitment: commitment, Nonce: 42, Timestamp: 123}
+	block, err := m.preflight(bh)
+	if err != nil {
+		t.Fatalf("preflight returned unexpected error: %v", err)
+	}
+	if block.Nonce != 42 || block.Timestamp != 123 || block.BlockCommitment != commitment {
+		t.Fatalf("preflight did not assemble submitted header fields onto the block: %+v", block)
+	}
+	if len(block.Transactions) != len(txs) {
+		t.Fatalf("preflight did not attach the cached transactions for the commitment")
+	}
+}
+
+func TestPipelineMetricsSnapshot(t *testing.T) {
+	var p pipelineMetrics
+	p.observeValidation(10*time.Millisecond, true)
+	p.observeValidation(20*time.Millisecond, false)
+	p.observeProcess(30 * time.Millisecond)
+
+	snap := p.snapshot()
+	if snap.ValidationCount != 2 {
+		t.Fatalf("ValidationCount = %d, want 2", snap.ValidationCount)
+	}
+	if snap.RejectedCount != 1 {
+		t.Fatalf("RejectedCount = %d, want 1", snap.RejectedCount)
+	}
+	if snap.ProcessCount != 1 {
+		t.Fatalf("ProcessCount = %d, want 1", snap.ProcessCount)
+	}
+	if snap.ValidationLatency != 15*time.Millisecond {
+		t.Fatalf("ValidationLatency = %v, want 15ms", snap.ValidationLatency)
+	}
+}
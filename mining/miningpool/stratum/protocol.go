@@ -0,0 +1,58 @@
+package stratum
+
+import "encoding/json"
+
+// Stratum method names, adapted from the usual mining.* convention to
+// Bytom's BlockHeader + Tensority work format.
+const (
+	methodSubscribe = "mining.subscribe"
+	methodAuthorize = "mining.authorize"
+	methodNotify    = "mining.notify"
+	methodSubmit    = "mining.submit"
+	methodSetDiff   = "mining.set_difficulty"
+)
+
+// request is a line-delimited JSON-RPC 1.0 style request, the dialect
+// spoken by the stratum mining protocol.
+type request struct {
+	ID     json.RawMessage   `json:"id"`
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+}
+
+// response mirrors a request's id with either a result or an error.
+type response struct {
+	ID     json.RawMessage `json:"id"`
+	Result interface{}     `json:"result,omitempty"`
+	Error  *string         `json:"error,omitempty"`
+}
+
+// notification is a server-initiated message (no id), used for
+// mining.notify and mining.set_difficulty pushes.
+type notification struct {
+	Method string        `json:"method"`
+	Params []interface{} `json:"params"`
+}
+
+// notifyParams is the payload pushed on mining.notify, one per fresh block
+// template. The job_id is an opaque handle the server resolves back to the
+// full BlockHeader (including BlockCommitment) on submit; Nonce and
+// Timestamp are left for the miner to fill in and return via mining.submit.
+type notifyParams struct {
+	JobID         string `json:"job_id"`
+	Version       uint64 `json:"version"`
+	Height        uint64 `json:"height"`
+	PreviousBlock string `json:"previous_block_hash"`
+	Timestamp     uint64 `json:"timestamp"`
+	Bits          uint64 `json:"bits"`
+	CleanJobs     bool   `json:"clean_jobs"`
+}
+
+// submitParams is the payload accepted by mining.submit.
+type submitParams struct {
+	Worker     string `json:"worker"`
+	JobID      string `json:"job_id"`
+	Extranonce string `json:"extranonce"`
+	Nonce      uint64 `json:"nonce"`
+	Timestamp  uint64 `json:"timestamp"`
+}
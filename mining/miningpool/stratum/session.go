@@ -0,0 +1,231 @@
+package stratum
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/bytom/event"
+	"github.com/bytom/protocol/bc/types"
+)
+
+// maxJobWindow bounds how many outstanding jobs a session remembers. A
+// miner submitting against anything older than this has fallen too far
+// behind CleanJobs to matter; without a bound, a long-lived connection would
+// otherwise accumulate one job per notify for its entire lifetime.
+const maxJobWindow = 4
+
+// nonceRangeBits is how many of the high bits of BlockHeader.Nonce are
+// reserved for a session's extranonce. Each session is handed a disjoint
+// 32-bit extranonce prefix (see Server.serve), and is expected to search
+// only the low nonceRangeBits of the nonce space, so that two sessions
+// mining the same template never grind the same nonces.
+const nonceRangeBits = 32
+
+// job is what a job_id resolves back to on mining.submit: everything in the
+// template except the fields the miner fills in (nonce, timestamp).
+type job struct {
+	header     types.BlockHeader
+	commitment types.BlockCommitment
+}
+
+// Session is one stratum connection: a subscribed, (optionally) authorized
+// miner polling for work via mining.notify pushes and returning shares via
+// mining.submit.
+type Session struct {
+	server        *Server
+	conn          net.Conn
+	extranonce    string
+	extranonceVal uint64
+	worker        string
+	authorized    bool
+	vardiff       *vardiff
+
+	writeMu sync.Mutex
+
+	jobsMu   sync.Mutex
+	jobs     map[string]*job
+	jobOrder []string
+	nextJob  uint64
+}
+
+func newSession(s *Server, conn net.Conn, extranonceVal uint64) *Session {
+	return &Session{
+		server:        s,
+		conn:          conn,
+		extranonce:    fmt.Sprintf("%08x", extranonceVal),
+		extranonceVal: extranonceVal,
+		vardiff:       newVardiff(),
+		jobs:          make(map[string]*job),
+	}
+}
+
+// run reads line-delimited JSON-RPC requests until the connection closes.
+func (sess *Session) run() {
+	defer sess.conn.Close()
+
+	scanner := bufio.NewScanner(sess.conn)
+	for scanner.Scan() {
+		var req request
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			log.WithFields(log.Fields{"err": err}).Warning("stratum: failed to decode request")
+			continue
+		}
+
+		if err := sess.dispatch(&req); err != nil {
+			log.WithFields(log.Fields{"err": err, "method": req.Method, "worker": sess.worker}).Warning("stratum: request failed")
+		}
+	}
+}
+
+func (sess *Session) dispatch(req *request) error {
+	switch req.Method {
+	case methodSubscribe:
+		return sess.handleSubscribe(req)
+	case methodAuthorize:
+		return sess.handleAuthorize(req)
+	case methodSubmit:
+		return sess.handleSubmit(req)
+	default:
+		return sess.reply(req.ID, nil, fmt.Errorf("unknown method %q", req.Method))
+	}
+}
+
+// handleSubscribe returns the session's extranonce. The miner is expected to
+// hold it fixed as the high nonceRangeBits bits of every submitted nonce and
+// only search the remaining low bits, so that concurrently connected
+// sessions working the same template never overlap their search space.
+func (sess *Session) handleSubscribe(req *request) error {
+	return sess.reply(req.ID, []interface{}{sess.extranonce}, nil)
+}
+
+func (sess *Session) handleAuthorize(req *request) error {
+	if len(req.Params) > 0 {
+		var worker string
+		if err := json.Unmarshal(req.Params[0], &worker); err == nil {
+			sess.worker = worker
+		}
+	}
+	sess.authorized = true
+
+	if err := sess.reply(req.ID, true, nil); err != nil {
+		return err
+	}
+	return sess.sendDifficulty(sess.vardiff.difficulty)
+}
+
+// handleSubmit validates a mining.submit against the session's assigned
+// extranonce range before forwarding it to MiningPool.SubmitWork, which
+// remains the sole authority on whether the share actually satisfies the
+// network difficulty; the session's vardiff target only paces how often
+// mining.set_difficulty is pushed and what difficulty is recorded against
+// the share, it does not gate acceptance.
+func (sess *Session) handleSubmit(req *request) error {
+	if !sess.authorized {
+		return sess.reply(req.ID, nil, errors.New("worker not authorized"))
+	}
+	if len(req.Params) == 0 {
+		return sess.reply(req.ID, nil, errors.New("missing submit params"))
+	}
+
+	var params submitParams
+	if err := json.Unmarshal(req.Params[0], &params); err != nil {
+		return sess.reply(req.ID, nil, err)
+	}
+
+	if params.Nonce>>nonceRangeBits != sess.extranonceVal {
+		sess.server.accounting.AddShare(sess.worker, sess.vardiff.difficulty, false)
+		return sess.reply(req.ID, nil, errors.New("nonce outside assigned extranonce range"))
+	}
+
+	sess.jobsMu.Lock()
+	j, ok := sess.jobs[params.JobID]
+	sess.jobsMu.Unlock()
+	if !ok {
+		sess.server.accounting.AddShare(sess.worker, sess.vardiff.difficulty, false)
+		return sess.reply(req.ID, nil, errors.New("unknown job_id"))
+	}
+
+	bh := j.header
+	bh.BlockCommitment = j.commitment
+	bh.Nonce = params.Nonce
+	bh.Timestamp = params.Timestamp
+
+	err := sess.server.pool.SubmitWork(&bh)
+	difficulty, changed := sess.vardiff.recordShare()
+	sess.server.accounting.AddShare(sess.worker, difficulty, err == nil)
+
+	if err != nil {
+		if replyErr := sess.reply(req.ID, nil, err); replyErr != nil || !changed {
+			return replyErr
+		}
+		return sess.sendDifficulty(difficulty)
+	}
+	if err := sess.reply(req.ID, true, nil); err != nil {
+		return err
+	}
+	if changed {
+		return sess.sendDifficulty(difficulty)
+	}
+	return nil
+}
+
+// sendDifficulty pushes a mining.set_difficulty frame so the miner knows
+// the target it should be submitting shares against.
+func (sess *Session) sendDifficulty(difficulty float64) error {
+	return sess.send(&notification{Method: methodSetDiff, Params: []interface{}{difficulty}})
+}
+
+// notify assigns tmpl a job id, pushes a mining.notify frame, and evicts the
+// oldest outstanding job once more than maxJobWindow are held.
+func (sess *Session) notify(tmpl event.NewBlockTemplateEvent) error {
+	sess.jobsMu.Lock()
+	sess.nextJob++
+	jobID := fmt.Sprintf("%x", sess.nextJob)
+	sess.jobs[jobID] = &job{header: tmpl.Header, commitment: tmpl.Commitment}
+	sess.jobOrder = append(sess.jobOrder, jobID)
+	if len(sess.jobOrder) > maxJobWindow {
+		var evicted string
+		evicted, sess.jobOrder = sess.jobOrder[0], sess.jobOrder[1:]
+		delete(sess.jobs, evicted)
+	}
+	sess.jobsMu.Unlock()
+
+	params := notifyParams{
+		JobID:         jobID,
+		Version:       tmpl.Header.Version,
+		Height:        tmpl.Height,
+		PreviousBlock: tmpl.Header.PreviousBlockHash.String(),
+		Timestamp:     tmpl.Header.Timestamp,
+		Bits:          tmpl.Header.Bits,
+		CleanJobs:     true,
+	}
+	return sess.send(&notification{Method: methodNotify, Params: []interface{}{params}})
+}
+
+func (sess *Session) reply(id json.RawMessage, result interface{}, err error) error {
+	resp := &response{ID: id, Result: result}
+	if err != nil {
+		msg := err.Error()
+		resp.Error = &msg
+	}
+	return sess.send(resp)
+}
+
+func (sess *Session) send(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	sess.writeMu.Lock()
+	defer sess.writeMu.Unlock()
+	_, err = sess.conn.Write(data)
+	return err
+}
@@ -0,0 +1,20 @@
+package stratum
+
+// ShareAccounter lets an operator plug in payout/accounting logic for
+// accepted and rejected shares without the stratum server needing to know
+// anything about payout schemes. AddShare is called once per authorized
+// mining.submit whose params decode: with accepted false for a nonce outside
+// the session's extranonce range or an unknown job_id, and otherwise with
+// accepted reflecting whether MiningPool.SubmitWork accepted it at the full
+// network difficulty; there is
+// currently no cheaper local check against the session's vardiff target, so
+// every forwarded share pays the cost of a real submission attempt.
+type ShareAccounter interface {
+	AddShare(worker string, difficulty float64, accepted bool)
+}
+
+// noopShareAccounter discards shares. It is the default when an operator
+// does not plug in payout logic.
+type noopShareAccounter struct{}
+
+func (noopShareAccounter) AddShare(worker string, difficulty float64, accepted bool) {}
@@ -0,0 +1,111 @@
+// Package stratum implements a line-delimited JSON-RPC stratum server
+// (mining.subscribe / mining.authorize / mining.notify / mining.submit)
+// backed by miningpool.MiningPool, adapted to Bytom's BlockHeader +
+// Tensority work format rather than the Bitcoin-style coinbase/merkle-branch
+// layout stock ASIC/GPU firmware expects. Talking to it still requires a
+// client that understands this dialect (nonce-range partitioning via
+// extranonce in place of extranonce2-in-coinbase); it is not a drop-in
+// replacement for a Stratum v1 pool.
+package stratum
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/bytom/event"
+	"github.com/bytom/mining/miningpool"
+)
+
+// Server accepts stratum connections and serves them from a single
+// MiningPool, pushing a fresh mining.notify to every session whenever the
+// pool produces a new template.
+type Server struct {
+	pool       *miningpool.MiningPool
+	accounting ShareAccounter
+
+	nextExtranonce uint64
+
+	mutex    sync.Mutex
+	sessions map[*Session]struct{}
+}
+
+// NewServer creates a stratum Server fronting pool. accounting may be nil,
+// in which case shares are not recorded anywhere beyond accept/reject.
+func NewServer(pool *miningpool.MiningPool, accounting ShareAccounter) *Server {
+	if accounting == nil {
+		accounting = noopShareAccounter{}
+	}
+	return &Server{
+		pool:       pool,
+		accounting: accounting,
+		sessions:   make(map[*Session]struct{}),
+	}
+}
+
+// ListenAndServe accepts connections on addr until the listener is closed
+// or Accept returns a permanent error.
+func (s *Server) ListenAndServe(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	go s.broadcastNewTemplates()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return err
+		}
+		go s.serve(conn)
+	}
+}
+
+// broadcastNewTemplates subscribes to the pool's NewBlockTemplateEvent and
+// pushes a mining.notify to every connected session for each one.
+func (s *Server) broadcastNewTemplates() {
+	sub, err := s.pool.Subscribe(event.NewBlockTemplateEvent{})
+	if err != nil {
+		log.Errorf("stratum: failed to subscribe NewBlockTemplateEvent: %v", err)
+		return
+	}
+	defer sub.Unsubscribe()
+
+	for obj := range sub.Chan() {
+		tmpl, ok := obj.(event.NewBlockTemplateEvent)
+		if !ok {
+			continue
+		}
+
+		s.mutex.Lock()
+		sessions := make([]*Session, 0, len(s.sessions))
+		for session := range s.sessions {
+			sessions = append(sessions, session)
+		}
+		s.mutex.Unlock()
+
+		for _, session := range sessions {
+			if err := session.notify(tmpl); err != nil {
+				log.WithFields(log.Fields{"err": err, "worker": session.worker}).Warning("stratum: failed to push mining.notify")
+			}
+		}
+	}
+}
+
+func (s *Server) serve(conn net.Conn) {
+	extranonce := atomic.AddUint64(&s.nextExtranonce, 1)
+	session := newSession(s, conn, extranonce)
+
+	s.mutex.Lock()
+	s.sessions[session] = struct{}{}
+	s.mutex.Unlock()
+
+	session.run()
+
+	s.mutex.Lock()
+	delete(s.sessions, session)
+	s.mutex.Unlock()
+}
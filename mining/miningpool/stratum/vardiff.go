@@ -0,0 +1,61 @@
+package stratum
+
+import "time"
+
+const (
+	defaultDifficulty = 1.0
+	minDifficulty     = 0.001
+	maxDifficulty     = 1 << 20
+
+	// vardiff retargets towards one share roughly every targetShareInterval.
+	targetShareInterval = 10 * time.Second
+	retargetWindow      = 30 * time.Second
+)
+
+// vardiff tracks a session's share rate and retargets its difficulty
+// towards submitting roughly one share per targetShareInterval, the way
+// ASIC/GPU stratum pools commonly self-tune per-connection difficulty.
+type vardiff struct {
+	difficulty  float64
+	lastRetime  time.Time
+	sharesSince int
+}
+
+func newVardiff() *vardiff {
+	return &vardiff{difficulty: defaultDifficulty, lastRetime: time.Now()}
+}
+
+// recordShare registers a share forwarded to the pool, whether or not the
+// pool accepted it, and retargets difficulty if a
+// retarget is due. It returns the (possibly unchanged) difficulty and
+// whether this call actually changed it, so the caller knows whether the
+// miner needs a fresh mining.set_difficulty.
+func (v *vardiff) recordShare() (difficulty float64, changed bool) {
+	v.sharesSince++
+
+	elapsed := time.Since(v.lastRetime)
+	if elapsed < retargetWindow {
+		return v.difficulty, false
+	}
+
+	avgInterval := elapsed / time.Duration(v.sharesSince)
+	// ratio > 1 means shares arrived faster than target (avgInterval smaller
+	// than targetShareInterval), so difficulty should rise; ratio < 1 means
+	// arrivals were slower, so difficulty should drop. Using
+	// avgInterval/targetShareInterval here would retarget backwards, driving
+	// difficulty down exactly when it needs to go up.
+	ratio := float64(targetShareInterval) / float64(avgInterval)
+
+	old := v.difficulty
+	v.difficulty *= ratio
+	if v.difficulty < minDifficulty {
+		v.difficulty = minDifficulty
+	}
+	if v.difficulty > maxDifficulty {
+		v.difficulty = maxDifficulty
+	}
+
+	v.lastRetime = time.Now()
+	v.sharesSince = 0
+	return v.difficulty, v.difficulty != old
+}
@@ -0,0 +1,44 @@
+package stratum
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRecordShareRaisesDifficultyWhenSharesArriveFast(t *testing.T) {
+	v := &vardiff{difficulty: defaultDifficulty, lastRetime: time.Now().Add(-retargetWindow - time.Second)}
+	v.sharesSince = 300 // far more than one share per targetShareInterval over the window
+
+	difficulty, changed := v.recordShare()
+
+	if !changed {
+		t.Fatal("recordShare did not report a change")
+	}
+	if difficulty <= defaultDifficulty {
+		t.Fatalf("difficulty = %v, want it raised above %v when shares arrive faster than target", difficulty, defaultDifficulty)
+	}
+}
+
+func TestRecordShareLowersDifficultyWhenSharesArriveSlow(t *testing.T) {
+	v := &vardiff{difficulty: defaultDifficulty, lastRetime: time.Now().Add(-retargetWindow - time.Second)}
+	v.sharesSince = 1 // one share over the whole window is far slower than target
+
+	difficulty, changed := v.recordShare()
+
+	if !changed {
+		t.Fatal("recordShare did not report a change")
+	}
+	if difficulty >= defaultDifficulty {
+		t.Fatalf("difficulty = %v, want it lowered below %v when shares arrive slower than target", difficulty, defaultDifficulty)
+	}
+}
+
+func TestRecordShareClampsToBounds(t *testing.T) {
+	v := &vardiff{difficulty: defaultDifficulty, lastRetime: time.Now().Add(-retargetWindow - time.Second)}
+	v.sharesSince = 100000000 // ratio of roughly 3e7, far past maxDifficulty
+
+	difficulty, _ := v.recordShare()
+	if difficulty > maxDifficulty {
+		t.Fatalf("difficulty = %v, want clamped to maxDifficulty %v", difficulty, maxDifficulty)
+	}
+}
@@ -0,0 +1,93 @@
+package miningpool
+
+import (
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/bytom/event"
+	"github.com/bytom/protocol"
+	"github.com/bytom/protocol/bc"
+)
+
+// unconfirmedBlockDepth is the default number of blocks the chain must
+// advance past a submitted block before it is resolved as confirmed or
+// reorged.
+const unconfirmedBlockDepth = 6
+
+type unconfirmedBlock struct {
+	height uint64
+	hash   bc.Hash
+}
+
+// UnconfirmedBlocks tracks blocks this pool has successfully submitted via
+// SubmitWork, and resolves each one as confirmed or reorged once the chain
+// has advanced depth blocks past it. It is modeled on go-ethereum/coreth's
+// miner/unconfirmed.go.
+type UnconfirmedBlocks struct {
+	depth  uint64
+	mutex  sync.Mutex
+	blocks []*unconfirmedBlock
+}
+
+// NewUnconfirmedBlocks creates a tracker that keeps at most depth blocks.
+func NewUnconfirmedBlocks(depth uint64) *UnconfirmedBlocks {
+	return &UnconfirmedBlocks{depth: depth}
+}
+
+// Insert records a newly submitted block. Anything already tracked at or
+// after height is dropped first, since the pool only ever submits in
+// ascending order and a later insert at the same height means the earlier
+// one never made it onto the canonical chain.
+func (u *UnconfirmedBlocks) Insert(height uint64, hash bc.Hash) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	for len(u.blocks) > 0 && u.blocks[len(u.blocks)-1].height >= height {
+		u.blocks = u.blocks[:len(u.blocks)-1]
+	}
+
+	u.blocks = append(u.blocks, &unconfirmedBlock{height: height, hash: hash})
+	if uint64(len(u.blocks)) > u.depth {
+		u.blocks = u.blocks[uint64(len(u.blocks))-u.depth:]
+	}
+}
+
+// splitDue partitions the tracked blocks into those still within depth of
+// height (remaining) and those old enough to resolve (due). It holds no lock
+// itself so it can be unit tested as a pure function.
+func splitDue(blocks []*unconfirmedBlock, height, depth uint64) (due, remaining []*unconfirmedBlock) {
+	for _, block := range blocks {
+		if height < block.height+depth {
+			remaining = append(remaining, block)
+		} else {
+			due = append(due, block)
+		}
+	}
+	return due, remaining
+}
+
+// Shift walks the tracked blocks and resolves (and drops) every one that is
+// now at least depth blocks below the new tip height, looking each up
+// against the canonical chain to tell confirmation from reorg, and posting
+// the result on dispatcher.
+func (u *UnconfirmedBlocks) Shift(height uint64, chain *protocol.Chain, dispatcher *event.Dispatcher) {
+	u.mutex.Lock()
+	due, remaining := splitDue(u.blocks, height, u.depth)
+	u.blocks = remaining
+	u.mutex.Unlock()
+
+	for _, block := range due {
+		canonicalBlock, err := chain.GetBlockByHeight(block.height)
+		if err != nil {
+			log.WithFields(log.Fields{"err": err, "height": block.height}).Warning("unconfirmed: failed to look up canonical block")
+			continue
+		}
+
+		if canonicalBlock.Hash() == block.hash {
+			dispatcher.Post(event.MinedBlockConfirmedEvent{Height: block.height, Hash: block.hash})
+		} else {
+			dispatcher.Post(event.MinedBlockReorgedEvent{Height: block.height, Hash: block.hash})
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package miningpool
+
+import (
+	"testing"
+
+	"github.com/bytom/protocol/bc"
+)
+
+func TestUnconfirmedBlocksInsertDropsStaleTail(t *testing.T) {
+	u := NewUnconfirmedBlocks(6)
+
+	u.Insert(10, bc.Hash{V0: 10})
+	u.Insert(11, bc.Hash{V0: 11})
+	u.Insert(11, bc.Hash{V0: 111})
+
+	if len(u.blocks) != 2 {
+		t.Fatalf("len(blocks) = %d, want 2", len(u.blocks))
+	}
+	if u.blocks[len(u.blocks)-1].hash != (bc.Hash{V0: 111}) {
+		t.Fatalf("re-inserting height 11 should replace the earlier entry at that height")
+	}
+}
+
+func TestUnconfirmedBlocksInsertTrimsToDepth(t *testing.T) {
+	u := NewUnconfirmedBlocks(2)
+
+	u.Insert(1, bc.Hash{V0: 1})
+	u.Insert(2, bc.Hash{V0: 2})
+	u.Insert(3, bc.Hash{V0: 3})
+
+	if len(u.blocks) != 2 {
+		t.Fatalf("len(blocks) = %d, want depth 2", len(u.blocks))
+	}
+	if u.blocks[0].height != 2 || u.blocks[1].height != 3 {
+		t.Fatalf("Insert should keep the most recent depth entries, got heights %d,%d", u.blocks[0].height, u.blocks[1].height)
+	}
+}
+
+func TestSplitDueResolvesOnlyBlocksPastDepth(t *testing.T) {
+	blocks := []*unconfirmedBlock{
+		{height: 10, hash: bc.Hash{V0: 10}},
+		{height: 12, hash: bc.Hash{V0: 12}},
+		{height: 14, hash: bc.Hash{V0: 14}},
+	}
+
+	due, remaining := splitDue(blocks, 16, 6)
+
+	if len(due) != 1 || due[0].height != 10 {
+		t.Fatalf("due = %+v, want only height 10 resolved at tip 16 with depth 6", due)
+	}
+	if len(remaining) != 2 || remaining[0].height != 12 || remaining[1].height != 14 {
+		t.Fatalf("remaining = %+v, want heights 12 and 14 kept", remaining)
+	}
+}
+
+func TestSplitDueNoneDueWithinDepth(t *testing.T) {
+	blocks := []*unconfirmedBlock{
+		{height: 20, hash: bc.Hash{V0: 20}},
+	}
+
+	due, remaining := splitDue(blocks, 21, 6)
+
+	if len(due) != 0 {
+		t.Fatalf("due = %+v, want none resolved yet", due)
+	}
+	if len(remaining) != 1 || remaining[0].height != 20 {
+		t.Fatalf("remaining = %+v, want height 20 untouched", remaining)
+	}
+}